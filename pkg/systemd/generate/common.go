@@ -23,22 +23,113 @@ func validateRestartPolicy(restart string) error {
 	return errors.Errorf("%s is not a valid restart policy", restart)
 }
 
-const headerTemplate = `# {{{{.ServiceName}}}}.service
-{{{{- if (eq .GenerateNoHeader false) }}}}
-# autogenerated by Podman {{{{.PodmanVersion}}}}
-{{{{- if .TimeStamp}}}}
-# {{{{.TimeStamp}}}}
-{{{{- end}}}}
-{{{{- end}}}}
+const headerTemplate = `# {{.ServiceName}}.service
+{{- if (eq .GenerateNoHeader false) }}
+# autogenerated by Podman {{.PodmanVersion}}
+{{- if .TimeStamp}}
+# {{.TimeStamp}}
+{{- end}}
+{{- end}}
 
 [Unit]
-Description=Podman {{{{.ServiceName}}}}.service
+Description=Podman {{.ServiceName}}.service
 Documentation=man:podman-generate-systemd(1)
 Wants=network.target
 After=network-online.target
-RequiresMountsFor={{{{.GraphRoot}}}} {{{{.RunRoot}}}}
+RequiresMountsFor={{.GraphRoot}} {{.RunRoot}}
 `
 
+// PortMapping is a single structured port published by a container or pod,
+// as parsed out of a -p/--publish flag by extractPublishFlags.
+type PortMapping struct {
+	// HostPort the socket unit listens on.
+	HostPort string
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+}
+
+// extractPublishFlags pulls the -p/--publish flags out of the specified
+// command and returns them as structured PortMappings, e.g. for use in a
+// companion ".socket" unit's ListenStream=/ListenDatagram= directives.
+// argCount is the number of last arguments which should not be inspected,
+// e.g. the container entrypoint.
+func extractPublishFlags(command []string, argCount int) []PortMapping {
+	var mappings []PortMapping
+	for i := 0; i < len(command)-argCount; i++ {
+		s := command[i]
+
+		var value string
+		switch {
+		case s == "-p", s == "--publish":
+			i++
+			if i >= len(command)-argCount {
+				continue
+			}
+			value = command[i]
+		case strings.HasPrefix(s, "-p="):
+			value = strings.TrimPrefix(s, "-p=")
+		case strings.HasPrefix(s, "--publish="):
+			value = strings.TrimPrefix(s, "--publish=")
+		default:
+			continue
+		}
+
+		mappings = append(mappings, parsePortMapping(value))
+	}
+	return mappings
+}
+
+// parsePortMapping parses a single -p/--publish value (e.g.
+// "8080:80", "127.0.0.1:8080:80/udp" or "80") into a PortMapping.
+func parsePortMapping(value string) PortMapping {
+	protocol := "tcp"
+	if idx := strings.LastIndex(value, "/"); idx != -1 {
+		protocol = value[idx+1:]
+		value = value[:idx]
+	}
+
+	// "host:container" or "ip:host:container"; a lone port is used as is
+	hostPort := value
+	if parts := strings.Split(value, ":"); len(parts) >= 2 {
+		hostPort = parts[len(parts)-2]
+	}
+
+	return PortMapping{HostPort: hostPort, Protocol: protocol}
+}
+
+// valuelessCreateFlags is the set of "podman run"/"podman create" flags that
+// take no value. Every other flag starting with "-" is assumed to consume
+// the next token as its value, so callers that scan a create command for
+// positional arguments (e.g. the image) don't mistake an unrecognized
+// flag's value for one.
+var valuelessCreateFlags = map[string]bool{
+	"-i": true, "--interactive": true,
+	"-t": true, "--tty": true,
+	"-d": true, "--detach": true,
+	"--replace":            true,
+	"--rm":                 true,
+	"--privileged":         true,
+	"--read-only":          true,
+	"--read-only-tmpfs":    true,
+	"-q": true, "--quiet": true,
+	"--init":             true,
+	"--no-hosts":         true,
+	"--oom-kill-disable": true,
+	"--sig-proxy":        true,
+	"--tls-verify":       true,
+	"--systemd":          true,
+}
+
+// flagConsumesValue reports whether flag (a token already known to start
+// with "-") is followed by a separate value token, as opposed to a
+// "--flag=value" token or a valueless boolean flag.
+func flagConsumesValue(flag string) bool {
+	if strings.Contains(flag, "=") {
+		return false
+	}
+	return !valuelessCreateFlags[flag]
+}
+
 // filterPodFlags removes --pod, --pod-id-file and --infra-conmon-pidfile from the specified command.
 // argCount is the number of last arguments which should not be filtered, e.g. the container entrypoint.
 func filterPodFlags(command []string, argCount int) []string {
@@ -111,6 +202,38 @@ func removeDetachArg(args []string, argCount int) []string {
 	return append(flagArgs, realArgs...)
 }
 
+// sdNotifyModeIgnore is the one --sdnotify value that is incompatible with
+// Type=notify units: it tells conmon/the container to never call sd_notify,
+// so systemd would wait for READY=1 forever.
+const sdNotifyModeIgnore = "ignore"
+
+// validateSdnotifyMode makes sure the create command does not already carry
+// an --sdnotify mode that is incompatible with generating a Type=notify
+// unit, e.g. "--sdnotify=ignore".  argCount is the number of last arguments
+// which should not be inspected, e.g. the container entrypoint.
+func validateSdnotifyMode(command []string, argCount int) error {
+	for i := 0; i < len(command)-argCount; i++ {
+		s := command[i]
+
+		var mode string
+		switch {
+		case s == "--sdnotify":
+			if i+1 < len(command)-argCount {
+				mode = command[i+1]
+			}
+		case strings.HasPrefix(s, "--sdnotify="):
+			mode = strings.TrimPrefix(s, "--sdnotify=")
+		default:
+			continue
+		}
+
+		if mode == sdNotifyModeIgnore {
+			return errors.New("container is configured with --sdnotify=ignore and cannot be used with a notify unit")
+		}
+	}
+	return nil
+}
+
 func removeReplaceArg(args []string, argCount int) []string {
 	// "--replace=false" could also be in the container entrypoint
 	// split them off so we do not remove it there
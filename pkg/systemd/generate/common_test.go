@@ -0,0 +1,67 @@
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		value string
+		want  PortMapping
+	}{
+		{"80", PortMapping{HostPort: "80", Protocol: "tcp"}},
+		{"8080:80", PortMapping{HostPort: "8080", Protocol: "tcp"}},
+		{"127.0.0.1:8080:80/udp", PortMapping{HostPort: "8080", Protocol: "udp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parsePortMapping(tt.value); got != tt.want {
+				t.Errorf("parsePortMapping(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPublishFlags(t *testing.T) {
+	command := []string{"-p", "8080:80", "--publish", "9090:90/udp", "--publish=100:100", "myimage"}
+	want := []PortMapping{
+		{HostPort: "8080", Protocol: "tcp"},
+		{HostPort: "9090", Protocol: "udp"},
+		{HostPort: "100", Protocol: "tcp"},
+	}
+
+	got := extractPublishFlags(command, 1)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractPublishFlags(%v, 1) = %+v, want %+v", command, got, want)
+	}
+}
+
+func TestValidateSdnotifyMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		wantErr bool
+	}{
+		{"no flag", []string{"myimage"}, false},
+		{"conmon mode", []string{"--sdnotify=conmon", "myimage"}, false},
+		{"ignore mode rejected", []string{"--sdnotify=ignore", "myimage"}, true},
+		{"ignore mode rejected, separate token", []string{"--sdnotify", "ignore", "myimage"}, true},
+		{"ignore mode in entrypoint args is not inspected", []string{"myimage", "--sdnotify=ignore"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argCount := 0
+			if tt.name == "ignore mode in entrypoint args is not inspected" {
+				argCount = 2
+			}
+			err := validateSdnotifyMode(tt.command, argCount)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSdnotifyMode(%v, %d) = %v, wantErr %v", tt.command, argCount, err, tt.wantErr)
+			}
+		})
+	}
+}
+
@@ -0,0 +1,358 @@
+package generate
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// containerInfo contains data required for generating a container's systemd
+// unit file.
+type containerInfo struct {
+	// ServiceName of the systemd service.
+	ServiceName string
+	// Name or ID of the container.
+	ContainerNameOrID string
+	// Type of the unit.
+	Type string
+	// NotifyAccess of the unit.
+	NotifyAccess string
+	// StopTimeout of the unit.
+	StopTimeout uint
+	// RestartPolicy of the unit.  Use one of "no, on-success, on-failure,
+	// on-abnormal, on-watchdog, on-abort, always".
+	RestartPolicy string
+	// PIDFile of the service.  Required for forking services.
+	PIDFile string
+	// ContainerIDFile used by the unit.
+	ContainerIDFile string
+	// GenerateTimestamp, if set the generated unit file has a time stamp.
+	GenerateTimestamp bool
+	// GenerateNoHeader, if set no header comment will be generated.
+	GenerateNoHeader bool
+	// BoundToServices are the services this service binds to.
+	BoundToServices []string
+	// PodmanVersion that is being used to generate the service.
+	PodmanVersion string
+	// Executable is the path to the podman executable.
+	Executable string
+	// RootDirectory of the container engine.
+	RootDirectory string
+	// TimeStamp at the time of generating the unit.
+	TimeStamp string
+	// CreateCommand is the full command plus arguments of the process the
+	// container has been created with.
+	CreateCommand []string
+	// RunRoot of the container engine.
+	RunRoot string
+	// GraphRoot of the container engine.
+	GraphRoot string
+	// Format of the generated unit.  One of FormatCommand (the classic
+	// "podman start" based unit) or FormatDeclarative (a [Container]
+	// section the unit is rendered from).
+	Format string
+	// SocketActivated, if set, generates a companion ".socket" unit (see
+	// CreateSocketSystemdUnit) and wires this service to start on first
+	// connection instead of at boot.
+	SocketActivated bool
+	// PreserveFDs is the number of inherited file descriptors to pass
+	// through to the container (one per published port) when
+	// SocketActivated is set. It should match len(Ports) of the
+	// companion socketInfo built from the same create command, e.g. via
+	// len(extractPublishFlags(command, argCount)).
+	PreserveFDs int
+	// SdNotifyMode is the --sdnotify mode ("conmon" or "container") baked
+	// into the ExecStart "podman run" invocation in FormatDeclarative when
+	// NotifyAccess is set. It has no effect in FormatCommand, whose
+	// ExecStart is a fixed "podman start" and therefore never forwards
+	// readiness on its own.
+	SdNotifyMode string
+}
+
+// Valid values for containerInfo.Format and podInfo.Format.
+const (
+	// FormatCommand generates a unit whose ExecStart is a "podman start" of
+	// the existing container.
+	FormatCommand = "command"
+	// FormatDeclarative generates a unit around a typed [Container] (or
+	// [Pod]) section instead of a raw command line, so the unit stays
+	// readable and diffable across podman releases.
+	FormatDeclarative = "declarative"
+)
+
+const containerTemplate = headerTemplate + `
+BindsTo={{- range $index, $value := .BoundToServices -}}{{if $index}} {{end}}{{ $value }}.service{{end}}
+After={{- range $index, $value := .BoundToServices -}}{{if $index}} {{end}}{{ $value }}.service{{end}}
+{{- if .SocketActivated}}
+Requires={{.ServiceName}}.socket
+After={{.ServiceName}}.socket
+{{- end}}
+
+[Service]
+Environment=PODMAN_SYSTEMD_UNIT=%n
+Restart={{.RestartPolicy}}
+TimeoutStopSec={{.StopTimeout}}
+ExecStartPre=/bin/rm -f {{.ContainerIDFile}}
+ExecStart={{.Executable}} start{{if .SocketActivated}} --preserve-fds={{.PreserveFDs}}{{end}} {{.ContainerNameOrID}}
+{{- if .SocketActivated}}
+Environment=LISTEN_FDS={{.PreserveFDs}}
+{{- end}}
+ExecStop={{.Executable}} stop --ignore --cidfile {{.ContainerIDFile}} -t {{.StopTimeout}} {{.ContainerNameOrID}}
+ExecStopPost={{.Executable}} rm --ignore -f --cidfile {{.ContainerIDFile}}
+{{- if .NotifyAccess}}
+Type=notify
+NotifyAccess={{.NotifyAccess}}
+{{- else}}
+PIDFile={{.PIDFile}}
+Type={{.Type}}
+{{- end}}
+{{- if not .SocketActivated}}
+
+[Install]
+WantedBy=multi-user.target default.target
+{{- end}}
+`
+
+// declarativeContainerTemplate renders a [Container] section describing the
+// container's spec instead of a raw "podman run" command line.  ExecStart is
+// still a concrete command, but it is assembled field-by-field from the same
+// [Container] values so that e.g. bumping Image= only ever touches that one
+// line of the unit.
+const declarativeContainerTemplate = headerTemplate + `
+[Container]
+Image={{.Spec.Image}}
+{{- range .Spec.Volumes}}
+Volume={{.}}
+{{- end}}
+{{- range .Spec.Ports}}
+PublishPort={{.}}
+{{- end}}
+{{- if .Spec.Network}}
+Network={{.Spec.Network}}
+{{- end}}
+{{- range .Spec.Env}}
+Environment={{.}}
+{{- end}}
+{{- if .Spec.Exec}}
+Exec={{.Spec.Exec}}
+{{- end}}
+
+[Service]
+Environment=PODMAN_SYSTEMD_UNIT=%n
+Restart={{.Info.RestartPolicy}}
+TimeoutStopSec={{.Info.StopTimeout}}
+ExecStartPre=/bin/rm -f {{.Info.ContainerIDFile}}
+ExecStart={{.ExecStart}}
+ExecStop={{.Info.Executable}} stop --ignore --cidfile {{.Info.ContainerIDFile}} -t {{.Info.StopTimeout}} {{.Info.ContainerNameOrID}}
+ExecStopPost={{.Info.Executable}} rm --ignore -f --cidfile {{.Info.ContainerIDFile}}
+{{- if .Info.NotifyAccess}}
+Type=notify
+NotifyAccess={{.Info.NotifyAccess}}
+{{- else}}
+PIDFile={{.Info.PIDFile}}
+Type={{.Info.Type}}
+{{- end}}
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+// containerSpec is the set of [Container] fields the declarative unit is
+// rendered from.  Unlike the "command" format, it is parsed straight out of
+// the create command's tokens instead of going through filterPodFlags/
+// filterCommonContainerFlags, since those exist to scrub podman-only
+// bookkeeping flags from an opaque command line, not to describe a spec.
+type containerSpec struct {
+	Image   string
+	Volumes []string
+	Ports   []string
+	Network string
+	Env     []string
+	// Exec is the escaped, space-joined entrypoint for display in the
+	// [Container] section's Exec= line.
+	Exec string
+	// execArgs are the same entrypoint tokens, unescaped, for reuse when
+	// assembling ExecStart (see buildDeclarativeExecStart).
+	execArgs []string
+}
+
+// parseContainerSpec extracts a containerSpec from the container's create
+// command.  argCount is the number of trailing arguments that make up the
+// container's entrypoint/command rather than podman flags.
+func parseContainerSpec(command []string, argCount int) containerSpec {
+	var spec containerSpec
+	flags := command[:len(command)-argCount]
+	exec := command[len(command)-argCount:]
+
+	for i := 0; i < len(flags); i++ {
+		s := flags[i]
+		switch {
+		case s == "-v", s == "--volume":
+			i++
+			if i < len(flags) {
+				spec.Volumes = append(spec.Volumes, flags[i])
+			}
+		case strings.HasPrefix(s, "--volume="):
+			spec.Volumes = append(spec.Volumes, strings.TrimPrefix(s, "--volume="))
+		case s == "-p", s == "--publish":
+			i++
+			if i < len(flags) {
+				spec.Ports = append(spec.Ports, flags[i])
+			}
+		case strings.HasPrefix(s, "--publish="):
+			spec.Ports = append(spec.Ports, strings.TrimPrefix(s, "--publish="))
+		case s == "--network", s == "--net":
+			i++
+			if i < len(flags) {
+				spec.Network = flags[i]
+			}
+		case strings.HasPrefix(s, "--network="), strings.HasPrefix(s, "--net="):
+			spec.Network = s[strings.Index(s, "=")+1:]
+		case s == "-e", s == "--env":
+			i++
+			if i < len(flags) {
+				spec.Env = append(spec.Env, flags[i])
+			}
+		case strings.HasPrefix(s, "--env="):
+			spec.Env = append(spec.Env, strings.TrimPrefix(s, "--env="))
+		case strings.HasPrefix(s, "-"):
+			// an unrecognized flag: skip its value (if it has one) so it
+			// isn't mistaken for the image below
+			if flagConsumesValue(s) {
+				i++
+			}
+		case spec.Image == "":
+			spec.Image = s
+		}
+	}
+
+	if len(exec) > 0 {
+		spec.execArgs = append([]string{}, exec...)
+	}
+	spec.Exec = strings.Join(escapeSystemdArguments(append([]string{}, exec...)), " ")
+	return spec
+}
+
+// buildDeclarativeExecStart assembles a "podman run" invocation from the
+// [Container] spec for the ExecStart= line. Every token is run through
+// escapeSystemdArguments together, so a volume, env or network value
+// containing a space or a "$"/"%" can't break the generated command line
+// the way splicing raw spec values into the template would.
+func buildDeclarativeExecStart(info *containerInfo, spec containerSpec) string {
+	sdNotifyMode := "conmon"
+	if info.NotifyAccess != "" && info.SdNotifyMode != "" {
+		sdNotifyMode = info.SdNotifyMode
+	}
+
+	args := []string{"run", "--cidfile", info.ContainerIDFile, "--cgroups=no-conmon", "--rm", "--sdnotify=" + sdNotifyMode, "-d", "--replace", "--name", info.ContainerNameOrID}
+	for _, v := range spec.Volumes {
+		args = append(args, "-v", v)
+	}
+	for _, p := range spec.Ports {
+		args = append(args, "-p", p)
+	}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+	}
+	for _, e := range spec.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, spec.Image)
+	args = append(args, spec.execArgs...)
+
+	return info.Executable + " " + strings.Join(escapeSystemdArguments(args), " ")
+}
+
+// CreateContainerSystemdUnit creates a systemd unit for the container with
+// the specified info.  In FormatCommand (the default) the generated unit
+// starts the existing container with "podman start"; the create command is
+// only filtered, validated and stored in info.CreateCommand.  In FormatDeclarative it instead renders a [Container] section
+// that the ExecStart line is assembled from.
+func CreateContainerSystemdUnit(info *containerInfo, command []string) (string, error) {
+	if err := validateRestartPolicy(info.RestartPolicy); err != nil {
+		return "", err
+	}
+
+	sort.Strings(info.BoundToServices)
+
+	if info.Format == FormatDeclarative {
+		if info.SocketActivated {
+			// declarativeContainerTemplate has no Requires=/After= socket
+			// wiring and buildDeclarativeExecStart doesn't thread
+			// --preserve-fds through, so silently accepting the
+			// combination would drop socket activation rather than honor
+			// it; reject it instead until the declarative template grows
+			// that support.
+			return "", errors.New("socket activation is not supported with FormatDeclarative")
+		}
+		spec := parseContainerSpec(command, 0)
+		tmpl, err := template.New("declarative_container_template").Parse(declarativeContainerTemplate)
+		if err != nil {
+			return "", errors.Wrap(err, "error parsing systemd template")
+		}
+
+		data := struct {
+			*containerInfo
+			Info      *containerInfo
+			Spec      containerSpec
+			ExecStart string
+		}{containerInfo: info, Info: info, Spec: spec, ExecStart: buildDeclarativeExecStart(info, spec)}
+
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", errors.Wrap(err, "error executing systemd template")
+		}
+		return out.String(), nil
+	}
+
+	if info.SocketActivated {
+		// derive the fd count from the command itself rather than trusting
+		// a caller-supplied number, so it can never drift out of sync with
+		// the companion socketInfo.Ports built from the same command
+		info.PreserveFDs = len(extractPublishFlags(command, 0))
+	}
+
+	command = filterPodFlags(command, 0)
+	command = filterCommonContainerFlags(command, 0)
+	command = removeDetachArg(command, 0)
+	command = removeReplaceArg(command, 0)
+
+	if info.NotifyAccess != "" {
+		// the unit's ExecStart is a fixed "podman start" invocation, not the
+		// create command, so there is nothing here to force --sdnotify=...
+		// onto; only reject a create command that would never signal
+		// readiness in the first place.
+		if err := validateSdnotifyMode(command, 0); err != nil {
+			return "", err
+		}
+	}
+
+	command = escapeSystemdArguments(command)
+
+	info.CreateCommand = command
+
+	tmpl, err := template.New("container_template").Parse(containerTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing systemd template")
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, info); err != nil {
+		return "", errors.Wrap(err, "error executing systemd template")
+	}
+
+	return out.String(), nil
+}
+
+func executable() string {
+	executable, err := os.Executable()
+	if err != nil {
+		logrus.Warnf("Error finding podman binary: %v", err)
+		return "podman"
+	}
+	return executable
+}
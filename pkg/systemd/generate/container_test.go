@@ -0,0 +1,112 @@
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContainerSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []string
+		argCount int
+		want     containerSpec
+	}{
+		{
+			name:     "simple",
+			command:  []string{"--name", "foo", "myimage"},
+			argCount: 0,
+			want:     containerSpec{Image: "myimage"},
+		},
+		{
+			name:     "unrecognized value flag does not get mistaken for the image",
+			command:  []string{"--entrypoint", "/bin/sh", "--name", "foo", "myimage:latest"},
+			argCount: 0,
+			want:     containerSpec{Image: "myimage:latest"},
+		},
+		{
+			name:     "network volume and env",
+			command:  []string{"--network", "bridge", "-v", "/host:/ctr", "-e", "FOO=bar", "myimage"},
+			argCount: 0,
+			want: containerSpec{
+				Image:   "myimage",
+				Volumes: []string{"/host:/ctr"},
+				Network: "bridge",
+				Env:     []string{"FOO=bar"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseContainerSpec(tt.command, tt.argCount)
+			got.Exec = ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseContainerSpec(%v, %d) = %+v, want %+v", tt.command, tt.argCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateContainerSystemdUnitCommandFormat(t *testing.T) {
+	info := &containerInfo{
+		ServiceName:       "foo",
+		ContainerNameOrID: "foo",
+		Type:              "forking",
+		PIDFile:           "/run/foo.pid",
+		ContainerIDFile:   "/run/foo.ctr-id",
+		RestartPolicy:     "no",
+		PodmanVersion:     "4.0.0",
+		Executable:        "/usr/bin/podman",
+		RunRoot:           "/run/containers/storage",
+		GraphRoot:         "/var/lib/containers/storage",
+	}
+
+	unit, err := CreateContainerSystemdUnit(info, []string{"--name", "foo", "myimage"})
+	if err != nil {
+		t.Fatalf("CreateContainerSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, "ExecStart=/usr/bin/podman start foo") {
+		t.Errorf("rendered unit missing expected ExecStart, got:\n%s", unit)
+	}
+}
+
+func TestCreateContainerSystemdUnitDeclarativeFormat(t *testing.T) {
+	info := &containerInfo{
+		ServiceName:       "foo",
+		ContainerNameOrID: "foo",
+		Type:              "forking",
+		PIDFile:           "/run/foo.pid",
+		ContainerIDFile:   "/run/foo.ctr-id",
+		RestartPolicy:     "no",
+		PodmanVersion:     "4.0.0",
+		Executable:        "/usr/bin/podman",
+		RunRoot:           "/run/containers/storage",
+		GraphRoot:         "/var/lib/containers/storage",
+		Format:            FormatDeclarative,
+	}
+
+	unit, err := CreateContainerSystemdUnit(info, []string{"--name", "foo", "myimage"})
+	if err != nil {
+		t.Fatalf("CreateContainerSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, "Image=myimage") {
+		t.Errorf("rendered unit missing expected [Container] section, got:\n%s", unit)
+	}
+}
+
+func TestCreateContainerSystemdUnitRejectsSocketActivatedDeclarative(t *testing.T) {
+	info := &containerInfo{
+		ServiceName:       "foo",
+		ContainerNameOrID: "foo",
+		RestartPolicy:     "no",
+		Executable:        "/usr/bin/podman",
+		Format:            FormatDeclarative,
+		SocketActivated:   true,
+	}
+
+	if _, err := CreateContainerSystemdUnit(info, []string{"-p", "8080:80", "myimage"}); err == nil {
+		t.Error("expected an error combining FormatDeclarative with SocketActivated, got nil")
+	}
+}
@@ -0,0 +1,220 @@
+package generate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// kubeInfo contains data required for generating a systemd unit file that
+// runs "podman kube play" against a companion Kubernetes-style manifest
+// instead of invoking "podman run"/"podman start" directly.
+type kubeInfo struct {
+	// ServiceName of the systemd service.
+	ServiceName string
+	// Executable is the path to the podman executable.
+	Executable string
+	// ManifestFilename is the path of the generated ".yaml" that
+	// ExecStart/ExecStop operate on.
+	ManifestFilename string
+	// RestartPolicy of the unit.
+	RestartPolicy string
+	// StopTimeout of the unit.
+	StopTimeout uint
+	// PodmanVersion that is being used to generate the service.
+	PodmanVersion string
+	// GenerateTimestamp, if set the generated unit file has a time stamp.
+	GenerateTimestamp bool
+	// GenerateNoHeader, if set no header comment will be generated.
+	GenerateNoHeader bool
+	// TimeStamp at the time of generating the unit.
+	TimeStamp string
+	// RunRoot of the container engine.
+	RunRoot string
+	// GraphRoot of the container engine.
+	GraphRoot string
+}
+
+const kubeTemplate = headerTemplate + `
+[Service]
+Restart={{.RestartPolicy}}
+TimeoutStopSec={{.StopTimeout}}
+ExecStart={{.Executable}} kube play --service-container=true --replace {{.ManifestFilename}}
+ExecStop={{.Executable}} kube down {{.ManifestFilename}}
+Type=notify
+NotifyAccess=all
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+// kubeWorkload is the minimal set of fields extracted from a container's (or
+// pod's) create command that is needed to render a Kubernetes-style Pod
+// manifest. It intentionally mirrors only what "podman generate systemd"
+// already knows about the workload rather than a full libpod inspection.
+type kubeWorkload struct {
+	name    string
+	image   string
+	ports   []string
+	volumes []string
+	env     []string
+	args    []string
+}
+
+// extractKubeWorkload filters the podman-only bookkeeping flags out of
+// command (the same way the "command" based unit does) and pulls out the
+// handful of fields needed to describe the workload as a Kubernetes Pod.
+func extractKubeWorkload(command []string, argCount int) kubeWorkload {
+	command = filterPodFlags(command, argCount)
+	command = filterCommonContainerFlags(command, argCount)
+
+	workload := kubeWorkload{}
+	image := ""
+	args := command[len(command)-argCount:]
+	flags := command[:len(command)-argCount]
+
+	for i := 0; i < len(flags); i++ {
+		s := flags[i]
+		switch {
+		case s == "--name":
+			i++
+			if i < len(flags) {
+				workload.name = flags[i]
+			}
+		case strings.HasPrefix(s, "--name="):
+			workload.name = strings.TrimPrefix(s, "--name=")
+		case s == "-p", s == "--publish":
+			i++
+			if i < len(flags) {
+				workload.ports = append(workload.ports, flags[i])
+			}
+		case strings.HasPrefix(s, "--publish="):
+			workload.ports = append(workload.ports, strings.TrimPrefix(s, "--publish="))
+		case s == "-v", s == "--volume":
+			i++
+			if i < len(flags) {
+				workload.volumes = append(workload.volumes, flags[i])
+			}
+		case strings.HasPrefix(s, "--volume="):
+			workload.volumes = append(workload.volumes, strings.TrimPrefix(s, "--volume="))
+		case s == "-e", s == "--env":
+			i++
+			if i < len(flags) {
+				workload.env = append(workload.env, flags[i])
+			}
+		case strings.HasPrefix(s, "--env="):
+			workload.env = append(workload.env, strings.TrimPrefix(s, "--env="))
+		case strings.HasPrefix(s, "-"):
+			// an unrecognized flag: skip its value (if it has one) so it
+			// isn't mistaken for the image below
+			if flagConsumesValue(s) {
+				i++
+			}
+		case image == "":
+			// first bare argument still left after filtering is the image
+			image = s
+		}
+	}
+
+	workload.image = image
+	if len(args) > 0 {
+		// args are stored raw: they land in the YAML manifest, not the
+		// unit's ExecStart, so systemd's escaping rules don't apply here.
+		workload.args = append([]string{}, args...)
+	}
+	return workload
+}
+
+// kubeYAML renders a minimal single-container Pod manifest good enough for
+// "podman kube play" to redeploy the workload that the container/pod was
+// originally created with.
+func kubeYAML(w kubeWorkload) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "apiVersion: v1\nkind: Pod\nmetadata:\n  name: %s\nspec:\n  containers:\n  - name: %s\n    image: %s\n", w.name, w.name, w.image)
+
+	if len(w.args) > 0 {
+		b.WriteString("    args:\n")
+		for _, a := range w.args {
+			fmt.Fprintf(&b, "    - %s\n", strconv.Quote(a))
+		}
+	}
+
+	if len(w.ports) > 0 {
+		b.WriteString("    ports:\n")
+		for _, p := range w.ports {
+			containerPort := p
+			if idx := strings.LastIndex(p, ":"); idx != -1 {
+				containerPort = p[idx+1:]
+			}
+			fmt.Fprintf(&b, "    - containerPort: %s\n", containerPort)
+		}
+	}
+
+	if len(w.env) > 0 {
+		b.WriteString("    env:\n")
+		for _, e := range w.env {
+			parts := strings.SplitN(e, "=", 2)
+			value := ""
+			if len(parts) == 2 {
+				value = parts[1]
+			}
+			fmt.Fprintf(&b, "    - name: %s\n      value: %s\n", parts[0], strconv.Quote(value))
+		}
+	}
+
+	if len(w.volumes) > 0 {
+		b.WriteString("    volumeMounts:\n")
+		for idx, v := range w.volumes {
+			// src:dst or src:dst:opts -- the third segment (mount options
+			// such as "ro") is podman/docker CLI syntax, not part of the
+			// container path, and must not leak into mountPath.
+			parts := strings.SplitN(v, ":", 3)
+			mountPath := parts[0]
+			if len(parts) >= 2 {
+				mountPath = parts[1]
+			}
+			fmt.Fprintf(&b, "    - name: vol-%d\n      mountPath: %s\n", idx, mountPath)
+		}
+		b.WriteString("  volumes:\n")
+		for idx, v := range w.volumes {
+			hostPath := strings.SplitN(v, ":", 3)[0]
+			fmt.Fprintf(&b, "  - name: vol-%d\n    hostPath:\n      path: %s\n", idx, hostPath)
+		}
+	}
+
+	return b.String()
+}
+
+// CreateKubeSystemdUnit generates a Kubernetes-style Pod manifest for the
+// container (or pod infra container) that was created with command, plus a
+// companion systemd unit that calls "podman kube play" on that manifest. The
+// pair allows the same workload to be redeployed declaratively instead of
+// replaying the original "podman run" invocation.
+func CreateKubeSystemdUnit(info *kubeInfo, command []string, argCount int) (unit string, manifest string, err error) {
+	if err := validateRestartPolicy(info.RestartPolicy); err != nil {
+		return "", "", err
+	}
+
+	workload := extractKubeWorkload(command, argCount)
+	if workload.image == "" {
+		return "", "", errors.New("unable to determine the container image from the create command")
+	}
+	if workload.name == "" {
+		workload.name = info.ServiceName
+	}
+
+	tmpl, err := template.New("kube_template").Parse(kubeTemplate)
+	if err != nil {
+		return "", "", errors.Wrap(err, "error parsing systemd template")
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, info); err != nil {
+		return "", "", errors.Wrap(err, "error executing systemd template")
+	}
+
+	return out.String(), kubeYAML(workload), nil
+}
@@ -0,0 +1,115 @@
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractKubeWorkload(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []string
+		argCount int
+		want     kubeWorkload
+	}{
+		{
+			name:     "simple",
+			command:  []string{"--name", "foo", "myimage"},
+			argCount: 0,
+			want:     kubeWorkload{name: "foo", image: "myimage"},
+		},
+		{
+			name:     "unrecognized value flag does not get mistaken for the image",
+			command:  []string{"--entrypoint", "/bin/sh", "--name", "foo", "myimage:latest"},
+			argCount: 0,
+			want:     kubeWorkload{name: "foo", image: "myimage:latest"},
+		},
+		{
+			name:     "ports volumes and env",
+			command:  []string{"-p", "8080:80", "-v", "/host:/ctr", "-e", "FOO=bar", "--name", "foo", "myimage"},
+			argCount: 0,
+			want: kubeWorkload{
+				name:    "foo",
+				image:   "myimage",
+				ports:   []string{"8080:80"},
+				volumes: []string{"/host:/ctr"},
+				env:     []string{"FOO=bar"},
+			},
+		},
+		{
+			name:     "entrypoint args are kept raw",
+			command:  []string{"--name", "foo", "myimage", "echo", "hello world"},
+			argCount: 2,
+			want: kubeWorkload{
+				name:  "foo",
+				image: "myimage",
+				args:  []string{"echo", "hello world"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractKubeWorkload(tt.command, tt.argCount)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractKubeWorkload(%v, %d) = %+v, want %+v", tt.command, tt.argCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubeYAMLVolumeWithMountOptions(t *testing.T) {
+	w := kubeWorkload{
+		name:    "foo",
+		image:   "myimage",
+		volumes: []string{"/host:/ctr:ro"},
+	}
+
+	out := kubeYAML(w)
+	if !strings.Contains(out, "mountPath: /ctr\n") {
+		t.Errorf("expected the :ro mount option to be stripped from mountPath, got:\n%s", out)
+	}
+	if !strings.Contains(out, "path: /host\n") {
+		t.Errorf("expected hostPath to stay /host, got:\n%s", out)
+	}
+}
+
+func TestCreateKubeSystemdUnitRenders(t *testing.T) {
+	info := &kubeInfo{
+		ServiceName:      "foo",
+		Executable:       "/usr/bin/podman",
+		ManifestFilename: "/etc/containers/systemd/foo.yaml",
+		RestartPolicy:    "no",
+		PodmanVersion:    "4.0.0",
+		RunRoot:          "/run/containers/storage",
+		GraphRoot:        "/var/lib/containers/storage",
+	}
+
+	unit, manifest, err := CreateKubeSystemdUnit(info, []string{"--name", "foo", "myimage"}, 0)
+	if err != nil {
+		t.Fatalf("CreateKubeSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, "ExecStart=/usr/bin/podman kube play --service-container=true --replace /etc/containers/systemd/foo.yaml") {
+		t.Errorf("rendered unit missing expected ExecStart, got:\n%s", unit)
+	}
+	if !strings.Contains(manifest, "image: myimage") {
+		t.Errorf("rendered manifest missing expected image, got:\n%s", manifest)
+	}
+}
+
+func TestKubeYAMLDoesNotDoubleEscapeArgs(t *testing.T) {
+	w := kubeWorkload{
+		name:  "foo",
+		image: "myimage",
+		args:  []string{"hello world", "50%"},
+	}
+
+	out := kubeYAML(w)
+	if !strings.Contains(out, `"hello world"`) {
+		t.Errorf("expected args to be quoted exactly once, got:\n%s", out)
+	}
+	if strings.Contains(out, "$$") || strings.Contains(out, "%%") {
+		t.Errorf("manifest args must not carry systemd escaping, got:\n%s", out)
+	}
+}
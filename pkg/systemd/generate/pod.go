@@ -0,0 +1,205 @@
+package generate
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// podInfo contains data required for generating a pod's systemd unit file.
+type podInfo struct {
+	// ServiceName of the systemd service.
+	ServiceName string
+	// Name or ID of the pod.
+	PodNameOrID string
+	// PIDFile of the pod's infra container.
+	PIDFile string
+	// PodIDFile used by the unit.
+	PodIDFile string
+	// RestartPolicy of the unit.
+	RestartPolicy string
+	// StopTimeout of the unit.
+	StopTimeout uint
+	// GenerateTimestamp, if set the generated unit file has a time stamp.
+	GenerateTimestamp bool
+	// GenerateNoHeader, if set no header comment will be generated.
+	GenerateNoHeader bool
+	// RequiredServices are the container services that must be started
+	// together with the pod.
+	RequiredServices []string
+	// PodmanVersion that is being used to generate the service.
+	PodmanVersion string
+	// Executable is the path to the podman executable.
+	Executable string
+	// TimeStamp at the time of generating the unit.
+	TimeStamp string
+	// RunRoot of the container engine.
+	RunRoot string
+	// GraphRoot of the container engine.
+	GraphRoot string
+	// Format of the generated unit.  One of FormatCommand or
+	// FormatDeclarative; see the constants in container.go.
+	Format string
+}
+
+const podTemplate = headerTemplate + `
+{{- range $index, $value := .RequiredServices -}}
+Wants={{ $value }}.service
+{{end -}}
+
+[Service]
+Restart={{.RestartPolicy}}
+TimeoutStopSec={{.StopTimeout}}
+ExecStartPre=/bin/rm -f {{.PodIDFile}}
+ExecStartPre={{.Executable}} pod create --infra-conmon-pidfile {{.PIDFile}} --pod-id-file {{.PodIDFile}} --name {{.PodNameOrID}}
+ExecStart={{.Executable}} pod start --pod-id-file {{.PodIDFile}}
+ExecStop={{.Executable}} pod stop --ignore --pod-id-file {{.PodIDFile}} -t {{.StopTimeout}}
+ExecStopPost={{.Executable}} pod rm --ignore -f --pod-id-file {{.PodIDFile}}
+PIDFile={{.PIDFile}}
+Type=forking
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+// declarativePodTemplate renders a [Pod] section describing the pod's spec
+// instead of spelling the "pod create" flags out in ExecStartPre.
+const declarativePodTemplate = headerTemplate + `
+[Pod]
+{{- if .Spec.Network}}
+Network={{.Spec.Network}}
+{{- end}}
+{{- range .Spec.Ports}}
+PublishPort={{.}}
+{{- end}}
+{{- range .Spec.Labels}}
+Label={{.}}
+{{- end}}
+{{- range $index, $value := .Info.RequiredServices -}}
+Wants={{ $value }}.service
+{{end -}}
+
+[Service]
+Restart={{.Info.RestartPolicy}}
+TimeoutStopSec={{.Info.StopTimeout}}
+ExecStartPre=/bin/rm -f {{.Info.PodIDFile}}
+ExecStartPre={{.ExecStartPre}}
+ExecStart={{.Info.Executable}} pod start --pod-id-file {{.Info.PodIDFile}}
+ExecStop={{.Info.Executable}} pod stop --ignore --pod-id-file {{.Info.PodIDFile}} -t {{.Info.StopTimeout}}
+ExecStopPost={{.Info.Executable}} pod rm --ignore -f --pod-id-file {{.Info.PodIDFile}}
+PIDFile={{.Info.PIDFile}}
+Type=forking
+
+[Install]
+WantedBy=multi-user.target default.target
+`
+
+// podSpec is the set of [Pod] fields the declarative unit is rendered from,
+// parsed straight out of the "pod create" command's tokens.
+type podSpec struct {
+	Network string
+	Ports   []string
+	Labels  []string
+}
+
+// parsePodSpec extracts a podSpec from the pod's create command.
+func parsePodSpec(command []string) podSpec {
+	var spec podSpec
+	for i := 0; i < len(command); i++ {
+		s := command[i]
+		switch {
+		case s == "--network", s == "--net":
+			i++
+			if i < len(command) {
+				spec.Network = command[i]
+			}
+		case strings.HasPrefix(s, "--network="), strings.HasPrefix(s, "--net="):
+			spec.Network = s[strings.Index(s, "=")+1:]
+		case s == "-p", s == "--publish":
+			i++
+			if i < len(command) {
+				spec.Ports = append(spec.Ports, command[i])
+			}
+		case strings.HasPrefix(s, "--publish="):
+			spec.Ports = append(spec.Ports, strings.TrimPrefix(s, "--publish="))
+		case s == "-l", s == "--label":
+			i++
+			if i < len(command) {
+				spec.Labels = append(spec.Labels, command[i])
+			}
+		case strings.HasPrefix(s, "--label="):
+			spec.Labels = append(spec.Labels, strings.TrimPrefix(s, "--label="))
+		}
+	}
+	return spec
+}
+
+// buildDeclarativePodExecStartPre assembles the "pod create" invocation
+// backing the declarative unit's second ExecStartPre= line. As with
+// buildDeclarativeExecStart, every token is run through
+// escapeSystemdArguments together, so a network name or label value
+// containing a space or a "$"/"%" can't break the generated command line
+// the way splicing raw spec values into the template would.
+func buildDeclarativePodExecStartPre(info *podInfo, spec podSpec) string {
+	args := []string{"pod", "create", "--infra-conmon-pidfile", info.PIDFile, "--pod-id-file", info.PodIDFile, "--name", info.PodNameOrID}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+	}
+	for _, p := range spec.Ports {
+		args = append(args, "-p", p)
+	}
+	for _, l := range spec.Labels {
+		args = append(args, "--label", l)
+	}
+
+	return info.Executable + " " + strings.Join(escapeSystemdArguments(args), " ")
+}
+
+// CreatePodSystemdUnit creates a systemd unit for the pod with the specified
+// info. It does not itself drive container creation; the returned unit
+// expects one companion unit per container, each of which BindsTo the pod's
+// service name as set up by CreateContainerSystemdUnit. command is the "pod
+// create" invocation the pod was created with; it is only consulted in
+// FormatDeclarative.
+func CreatePodSystemdUnit(info *podInfo, command []string) (string, error) {
+	if err := validateRestartPolicy(info.RestartPolicy); err != nil {
+		return "", err
+	}
+
+	sort.Strings(info.RequiredServices)
+
+	if info.Format == FormatDeclarative {
+		spec := parsePodSpec(command)
+		tmpl, err := template.New("declarative_pod_template").Parse(declarativePodTemplate)
+		if err != nil {
+			return "", errors.Wrap(err, "error parsing systemd template")
+		}
+
+		data := struct {
+			*podInfo
+			Info         *podInfo
+			Spec         podSpec
+			ExecStartPre string
+		}{podInfo: info, Info: info, Spec: spec, ExecStartPre: buildDeclarativePodExecStartPre(info, spec)}
+
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", errors.Wrap(err, "error executing systemd template")
+		}
+		return out.String(), nil
+	}
+
+	tmpl, err := template.New("pod_template").Parse(podTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing systemd template")
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, info); err != nil {
+		return "", errors.Wrap(err, "error executing systemd template")
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,93 @@
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePodSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    podSpec
+	}{
+		{
+			name:    "empty",
+			command: []string{"--name", "foo"},
+			want:    podSpec{},
+		},
+		{
+			name:    "network ports and labels",
+			command: []string{"--network", "bridge", "-p", "8080:80", "--label", "env=prod"},
+			want: podSpec{
+				Network: "bridge",
+				Ports:   []string{"8080:80"},
+				Labels:  []string{"env=prod"},
+			},
+		},
+		{
+			name:    "equals form",
+			command: []string{"--network=bridge", "--publish=8080:80", "--label=env=prod"},
+			want: podSpec{
+				Network: "bridge",
+				Ports:   []string{"8080:80"},
+				Labels:  []string{"env=prod"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parsePodSpec(tt.command)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePodSpec(%v) = %+v, want %+v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePodSystemdUnitCommandFormat(t *testing.T) {
+	info := &podInfo{
+		ServiceName:   "foo-pod",
+		PodNameOrID:   "foo",
+		PIDFile:       "/run/foo-infra.pid",
+		PodIDFile:     "/run/foo.pod-id",
+		RestartPolicy: "no",
+		PodmanVersion: "4.0.0",
+		Executable:    "/usr/bin/podman",
+		RunRoot:       "/run/containers/storage",
+		GraphRoot:     "/var/lib/containers/storage",
+	}
+
+	unit, err := CreatePodSystemdUnit(info, nil)
+	if err != nil {
+		t.Fatalf("CreatePodSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, "ExecStart=/usr/bin/podman pod start --pod-id-file /run/foo.pod-id") {
+		t.Errorf("rendered unit missing expected ExecStart, got:\n%s", unit)
+	}
+}
+
+func TestCreatePodSystemdUnitDeclarativeFormatEscapesExecStartPre(t *testing.T) {
+	info := &podInfo{
+		ServiceName:   "foo-pod",
+		PodNameOrID:   "foo",
+		PIDFile:       "/run/foo-infra.pid",
+		PodIDFile:     "/run/foo.pod-id",
+		RestartPolicy: "no",
+		PodmanVersion: "4.0.0",
+		Executable:    "/usr/bin/podman",
+		RunRoot:       "/run/containers/storage",
+		GraphRoot:     "/var/lib/containers/storage",
+		Format:        FormatDeclarative,
+	}
+
+	unit, err := CreatePodSystemdUnit(info, []string{"--label", "note=hello world"})
+	if err != nil {
+		t.Fatalf("CreatePodSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, `--label "note=hello world"`) {
+		t.Errorf("expected the label value to be escaped as a single ExecStartPre token, got:\n%s", unit)
+	}
+}
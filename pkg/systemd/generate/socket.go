@@ -0,0 +1,67 @@
+package generate
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// socketInfo contains data required for generating a companion ".socket"
+// unit for a socket-activated container service.
+type socketInfo struct {
+	// ServiceName of the systemd service this socket activates.  The
+	// socket unit shares the same base name (e.g. "foo.socket" activates
+	// "foo.service").
+	ServiceName string
+	// PodmanVersion that is being used to generate the unit.
+	PodmanVersion string
+	// GenerateTimestamp, if set the generated unit file has a time stamp.
+	GenerateTimestamp bool
+	// GenerateNoHeader, if set no header comment will be generated.
+	GenerateNoHeader bool
+	// TimeStamp at the time of generating the unit.
+	TimeStamp string
+	// RunRoot of the container engine.
+	RunRoot string
+	// GraphRoot of the container engine.
+	GraphRoot string
+	// Ports published by the container, turned into ListenStream=/
+	// ListenDatagram= directives.
+	Ports []PortMapping
+}
+
+const socketTemplate = headerTemplate + `
+[Socket]
+{{- range .Ports}}
+{{- if eq .Protocol "udp"}}
+ListenDatagram={{.HostPort}}
+{{- else}}
+ListenStream={{.HostPort}}
+{{- end}}
+{{- end}}
+
+[Install]
+WantedBy=sockets.target
+`
+
+// CreateSocketSystemdUnit creates a companion ".socket" unit that lazily
+// starts the container's ".service" on first connection.  info.Ports is typically
+// the result of extractPublishFlags run over the container's create command.
+func CreateSocketSystemdUnit(info *socketInfo) (string, error) {
+	if len(info.Ports) == 0 {
+		return "", errors.New("cannot generate a socket unit for a container that does not publish any ports")
+	}
+
+	tmpl, err := template.New("socket_template").Parse(socketTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing systemd template")
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, info); err != nil {
+		return "", errors.Wrap(err, "error executing systemd template")
+	}
+
+	return out.String(), nil
+}
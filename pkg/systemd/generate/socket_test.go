@@ -0,0 +1,37 @@
+package generate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateSocketSystemdUnitRenders(t *testing.T) {
+	info := &socketInfo{
+		ServiceName:   "foo",
+		PodmanVersion: "4.0.0",
+		RunRoot:       "/run/containers/storage",
+		GraphRoot:     "/var/lib/containers/storage",
+		Ports: []PortMapping{
+			{HostPort: "8080", Protocol: "tcp"},
+			{HostPort: "9090", Protocol: "udp"},
+		},
+	}
+
+	unit, err := CreateSocketSystemdUnit(info)
+	if err != nil {
+		t.Fatalf("CreateSocketSystemdUnit: %v", err)
+	}
+	if !strings.Contains(unit, "ListenStream=8080") {
+		t.Errorf("rendered unit missing expected ListenStream, got:\n%s", unit)
+	}
+	if !strings.Contains(unit, "ListenDatagram=9090") {
+		t.Errorf("rendered unit missing expected ListenDatagram, got:\n%s", unit)
+	}
+}
+
+func TestCreateSocketSystemdUnitRejectsNoPorts(t *testing.T) {
+	info := &socketInfo{ServiceName: "foo"}
+	if _, err := CreateSocketSystemdUnit(info); err == nil {
+		t.Error("expected an error for a socket unit with no published ports, got nil")
+	}
+}